@@ -0,0 +1,526 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"github.com/vitovt/go_check_links/warc"
+)
+
+// cssURLRegexp matches CSS url(...) references, e.g. in an inline <style>
+// block or a fetched .css file: background: url('img/bg.png').
+var cssURLRegexp = regexp.MustCompile(`url\(\s*['"]?([^'")\s]+)['"]?\s*\)`)
+
+// cssImportRegexp matches CSS @import references, both the url(...) and
+// bare-string forms: @import url(a.css); / @import "b.css";
+var cssImportRegexp = regexp.MustCompile(`@import\s+(?:url\(\s*['"]?([^'")\s]+)['"]?\s*\)|['"]([^'"]+)['"])`)
+
+// LinkStatus is the outcome of checking one link. Reason explains why a
+// link was skipped or failed when that isn't obvious from Status/Err alone
+// (e.g. "scope", "robots", "rate-limit", "http"). Referer is the URL of the
+// page this link was found on, empty for the start URL, letting reports
+// reconstruct the chain of pages that led to a broken link.
+type LinkStatus struct {
+	URL          string
+	Status       int
+	Err          error
+	Reason       string
+	Referer      string
+	LastModified string
+}
+
+// Crawler walks a site starting from startURL, reporting the status of
+// every link it finds. The frontier (URLs queued to be fetched) is tracked
+// separately from the visited set (URLs whose outcome is already known) so
+// that a CrawlState can resume a previous run without re-fetching pages it
+// already has an answer for.
+type Crawler struct {
+	startURL  *url.URL
+	client    *http.Client
+	results   chan LinkStatus
+	taskWG    sync.WaitGroup // outstanding crawlTasks: pushed but not yet finished
+	userAgent string
+
+	delay        time.Duration
+	debug        bool
+	maxNum       int
+	visitedCount int
+
+	frontierSeen map[string]bool
+	frontierLock sync.Mutex
+
+	state CrawlState   // optional, persists queue/visited across restarts
+	warc  *warc.Writer // optional, records every fetch as WARC records
+	scope Scope        // decides whether to descend, fetch-only, or drop a link
+
+	concurrency int
+	queue       *frontier
+	limiter     *hostLimiter
+	robots      *RobotsCache
+
+	domainMode  bool // --mode domains: HEAD-probe primary child links and cap body size before parsing
+	maxBodySize int64
+}
+
+// crawlerUserAgent is the User-Agent header sent with every request.
+const crawlerUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/115.0.0.0 Safari/537.36"
+
+// NewCrawler initializes a crawler with a given starting URL.
+func NewCrawler(startURL string, ignoreCert bool, delay time.Duration, timeout time.Duration, debug bool, maxNum int, state CrawlState, warcWriter *warc.Writer, scope Scope, concurrency int, perHostQPS float64, domainMode bool, maxBodySize int64) (*Crawler, error) {
+	u, err := url.Parse(startURL)
+	if err != nil {
+		return nil, err
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create a custom transport with optional certificate check ignoring
+	transport := &http.Transport{
+		// Optional: custom settings, proxies, timeouts, etc.
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: ignoreCert},
+	}
+
+	client := &http.Client{
+		Jar:       jar,
+		Transport: transport,
+		Timeout:   timeout,
+	}
+
+	userAgent := crawlerUserAgent
+
+	if concurrency <= 0 {
+		concurrency = 10
+	}
+
+	return &Crawler{
+		startURL:     u,
+		client:       client,
+		results:      make(chan LinkStatus, 1000),
+		userAgent:    userAgent,
+		delay:        delay,
+		debug:        debug,
+		maxNum:       maxNum,
+		frontierSeen: make(map[string]bool),
+		state:        state,
+		warc:         warcWriter,
+		scope:        scope,
+		concurrency:  concurrency,
+		queue:        newFrontier(),
+		limiter:      newHostLimiter(perHostQPS),
+		robots:       NewRobotsCache(client, userAgent),
+		domainMode:   domainMode,
+		maxBodySize:  maxBodySize,
+	}, nil
+}
+
+// SeedExtra queues additional start tasks alongside (or instead of) the
+// crawler's own startURL, e.g. registrable domains a previous `--mode
+// domains` run already discovered and persisted to a DomainLog.
+func (c *Crawler) SeedExtra(urls []string) {
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		c.push(crawlTask{link: Link{URL: u, Type: LinkTypePrimary}, depth: 0})
+	}
+}
+
+// Run starts a fixed-size pool of workers draining the frontier, resuming
+// any pending queue left over from a previous run with the same CrawlState
+// first.
+func (c *Crawler) Run(ctx context.Context) {
+	resumed := c.resumeFromState(ctx)
+
+	if !resumed {
+		c.push(crawlTask{link: Link{URL: c.startURL, Type: LinkTypePrimary}, depth: 0})
+	}
+
+	// Close the frontier once every outstanding task has been processed,
+	// so the idle workers below can return.
+	go func() {
+		c.taskWG.Wait()
+		c.queue.close()
+	}()
+
+	var workers sync.WaitGroup
+	for i := 0; i < c.concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				task, ok := c.queue.pop()
+				if !ok {
+					return
+				}
+				c.processTask(ctx, task)
+			}
+		}()
+	}
+
+	go func() {
+		workers.Wait()
+		close(c.results)
+	}()
+}
+
+// push queues t and records it as outstanding work.
+func (c *Crawler) push(t crawlTask) {
+	c.taskWG.Add(1)
+	c.queue.push(t)
+}
+
+// resumeFromState re-enqueues everything left in the CrawlState's pending
+// queue from a previous, interrupted run. It reports whether anything was
+// resumed.
+func (c *Crawler) resumeFromState(ctx context.Context) bool {
+	if c.state == nil {
+		return false
+	}
+
+	pending, err := c.state.PendingQueue()
+	if err != nil || len(pending) == 0 {
+		return false
+	}
+
+	for rawURL, rawReferer := range pending {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			continue
+		}
+		var referer *url.URL
+		if rawReferer != "" {
+			referer, _ = url.Parse(rawReferer)
+		}
+
+		// Don't pre-mark frontierSeen here: markQueued (called from
+		// processTask once this task is popped) is what actually records
+		// it and re-enqueues it in the state; pre-marking it would make
+		// that later markQueued call think it's a duplicate and drop the
+		// task without ever fetching it.
+		//
+		// Depth and link type aren't persisted, so a resumed link is
+		// treated as a fresh primary link at depth 0; a DepthScope will
+		// simply re-measure depth from here rather than from the
+		// original start URL.
+		c.push(crawlTask{link: Link{URL: u, Type: LinkTypePrimary}, referer: referer, depth: 0})
+	}
+	return true
+}
+
+// Wait waits for the crawl results to finish and returns them.
+func (c *Crawler) Wait() []LinkStatus {
+	var allResults []LinkStatus
+	for r := range c.results {
+		allResults = append(allResults, r)
+	}
+	return allResults
+}
+
+// markQueued records u as queued and reports whether it should be fetched:
+// false if it was already queued in this run, if the maxNum cap has been
+// reached, or, with a CrawlState, if it was already fetched in a previous
+// run.
+func (c *Crawler) markQueued(u, referer string) bool {
+	c.frontierLock.Lock()
+	if c.maxNum > 0 && c.visitedCount >= c.maxNum {
+		c.frontierLock.Unlock()
+		return false
+	}
+	if c.frontierSeen[u] {
+		c.frontierLock.Unlock()
+		return false
+	}
+	c.frontierSeen[u] = true
+	c.visitedCount++
+	c.frontierLock.Unlock()
+
+	if c.state != nil {
+		if done, err := c.state.IsVisited(u); err == nil && done {
+			return false
+		}
+		_ = c.state.Enqueue(u, referer)
+	}
+	return true
+}
+
+func (c *Crawler) markVisited(u string, status int, fetchErr error) {
+	if c.state != nil {
+		_ = c.state.MarkVisited(u, status, fetchErr)
+	}
+}
+
+// processTask fetches one queued link, checks it, and if the scope allows
+// descending and it turns out to be an HTML page, extracts its own links
+// and pushes them back onto the frontier.
+func (c *Crawler) processTask(ctx context.Context, task crawlTask) {
+	defer c.taskWG.Done()
+
+	link, referer, depth := task.link, task.referer, task.depth
+
+	u := link.URL
+	uStr := u.String()
+	refererStr := ""
+	if referer != nil {
+		refererStr = referer.String()
+	}
+
+	decision := c.scope.Decide(link, depth)
+	if decision == ScopeDrop {
+		// Scope-dropped links skip markQueued (they're never fetched, so
+		// there's nothing to enqueue or persist), but still need the same
+		// frontierSeen dedup, or else a link repeated across every page of
+		// a site (e.g. a shared footer link to an out-of-scope host)
+		// would be reported once per referring page instead of once.
+		c.frontierLock.Lock()
+		alreadySeen := c.frontierSeen[uStr]
+		c.frontierSeen[uStr] = true
+		c.frontierLock.Unlock()
+		if alreadySeen {
+			return
+		}
+		c.results <- LinkStatus{URL: uStr, Reason: "scope", Referer: refererStr}
+		return
+	}
+
+	if !c.markQueued(uStr, refererStr) {
+		return
+	}
+
+	if err := c.politenessCheck(ctx, u); err != nil {
+		reason := "rate-limit"
+		if err == errRobotsDisallowed {
+			reason, err = "robots", nil
+		}
+		c.results <- LinkStatus{URL: uStr, Err: err, Reason: reason, Referer: refererStr}
+		c.markVisited(uStr, 0, err)
+		return
+	}
+
+	// Random small delay to mimic human browsing
+	// If delay is set, sleep a random duration up to that delay
+	if c.delay > 0 {
+		time.Sleep(time.Duration(rand.Int63n(int64(c.delay))))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", uStr, nil)
+	if err != nil {
+		c.results <- LinkStatus{URL: uStr, Err: err, Referer: refererStr}
+		c.markVisited(uStr, 0, err)
+		return
+	}
+
+	// Set some "browser-like" headers
+	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,image/apng,*/*;q=0.8")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	if referer != nil {
+		req.Header.Set("Referer", referer.String())
+	}
+
+	resp, err := c.client.Do(req)
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	reason := ""
+	if err != nil || (status >= 400 && status < 600) {
+		reason = "http"
+	}
+	lastModified := ""
+	if resp != nil {
+		lastModified = resp.Header.Get("Last-Modified")
+	}
+	c.results <- LinkStatus{URL: uStr, Status: status, Err: err, Reason: reason, Referer: refererStr, LastModified: lastModified}
+
+	if err != nil {
+		// Can't proceed if request failed
+		c.markVisited(uStr, status, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	// Buffer the body up front so it can be parsed, dumped for -D, and
+	// written to the WARC file without each needing its own read of the
+	// (non-rewindable) response body.
+	bodyReader := io.Reader(resp.Body)
+	if c.domainMode && c.maxBodySize > 0 {
+		bodyReader = io.LimitReader(resp.Body, c.maxBodySize)
+	}
+	body, err := io.ReadAll(bodyReader)
+	if err != nil {
+		c.markVisited(uStr, status, err)
+		return
+	}
+
+	if c.warc != nil {
+		if err := c.warc.WriteExchange(uStr, req, resp, body, time.Now()); err != nil {
+			fmt.Printf("WARC: failed to record %s: %v\n", uStr, err)
+		}
+	}
+
+	c.markVisited(uStr, status, nil)
+
+	if c.debug {
+		fmt.Printf("DEBUG: %s (%d bytes)\n%s\n", uStr, len(body), body)
+	}
+
+	var found []Link
+
+	// CSS url()/@import references can appear in inline <style> blocks of
+	// an HTML page as well as in standalone .css files fetched as a related
+	// asset, so scan the raw body regardless of content type or decision:
+	// these children are themselves related assets, so they'll be checked
+	// for brokenness but not recursed into either.
+	found = append(found, extractCSSLinks(body, u)...)
+
+	// Beyond that, fetch-only links (e.g. images under a SeedScope) are
+	// checked for brokenness above but never recursed into.
+	if decision == ScopeDescend {
+		ct := resp.Header.Get("Content-Type")
+		if strings.Contains(strings.ToLower(ct), "text/html") {
+			if doc, err := html.Parse(bytes.NewReader(body)); err == nil {
+				found = append(found, extractLinks(doc, u)...)
+			}
+		}
+	}
+
+	for _, child := range found {
+		if c.domainMode && child.Type == LinkTypePrimary && !c.probeIsHTML(ctx, child.URL) {
+			continue
+		}
+		c.push(crawlTask{link: child, referer: u, depth: depth + 1})
+	}
+}
+
+// errRobotsDisallowed is returned by politenessCheck when robots.txt
+// disallows u, distinguishing that case from a rate-limiter wait failing.
+var errRobotsDisallowed = errors.New("crawler: disallowed by robots.txt")
+
+// politenessCheck blocks until u may be fetched without violating
+// robots.txt or the per-host rate limiter, used before every GET or HEAD
+// request the crawler makes. It returns errRobotsDisallowed if robots.txt
+// forbids u, or the context/limiter error if the rate-limit wait fails.
+func (c *Crawler) politenessCheck(ctx context.Context, u *url.URL) error {
+	rules := c.robots.Get(ctx, u)
+	if !rules.allowed(u.EscapedPath()) {
+		return errRobotsDisallowed
+	}
+
+	var crawlDelay time.Duration
+	if rules != nil {
+		crawlDelay = rules.crawlDelay
+	}
+	if limiter := c.limiter.forHost(u.Host, crawlDelay); limiter != nil {
+		if err := limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// probeIsHTML issues a HEAD request and reports whether the response
+// Content-Type is text/html. Used by --mode domains to avoid queuing a
+// full GET (and branching the discovery further) for links that turn out
+// to be binaries, feeds, etc. Subject to the same robots.txt and per-host
+// rate limiting as a regular fetch.
+func (c *Crawler) probeIsHTML(ctx context.Context, u *url.URL) bool {
+	if err := c.politenessCheck(ctx, u); err != nil {
+		return false
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "HEAD", u.String(), nil)
+	if err != nil {
+		return false
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "text/html")
+}
+
+// extractLinks finds primary navigational links (<a>, <frame>) and related
+// asset links (<img>, <script>, <link>, <iframe>) from the parsed HTML and
+// returns them as absolute, tagged Links.
+func extractLinks(n *html.Node, base *url.URL) []Link {
+	var links []Link
+	var f func(*html.Node)
+	f = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			var keyAttr string
+			var linkType LinkType
+			switch node.Data {
+			case "a", "frame":
+				keyAttr, linkType = "href", LinkTypePrimary
+			case "img", "script", "iframe":
+				keyAttr, linkType = "src", LinkTypeRelated
+			case "link":
+				keyAttr, linkType = "href", LinkTypeRelated
+			}
+			if keyAttr != "" {
+				for _, attr := range node.Attr {
+					if attr.Key == keyAttr {
+						u, err := url.Parse(strings.TrimSpace(attr.Val))
+						if err == nil {
+							links = append(links, Link{URL: base.ResolveReference(u), Type: linkType})
+						}
+					}
+				}
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			f(child)
+		}
+	}
+	f(n)
+	return links
+}
+
+// extractCSSLinks finds CSS url(...) and @import references in body and
+// returns them as absolute, LinkTypeRelated Links.
+func extractCSSLinks(body []byte, base *url.URL) []Link {
+	var links []Link
+	addMatches := func(matches [][]byte) {
+		for _, raw := range matches {
+			if len(raw) == 0 {
+				continue
+			}
+			u, err := url.Parse(strings.TrimSpace(string(raw)))
+			if err != nil {
+				continue
+			}
+			links = append(links, Link{URL: base.ResolveReference(u), Type: LinkTypeRelated})
+		}
+	}
+
+	for _, m := range cssURLRegexp.FindAllSubmatch(body, -1) {
+		addMatches(m[1:2])
+	}
+	for _, m := range cssImportRegexp.FindAllSubmatch(body, -1) {
+		addMatches(m[1:3])
+	}
+	return links
+}
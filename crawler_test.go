@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestCrawlerResumesPendingQueue is a regression test for a bug where every
+// URL recovered from a previous run's persisted queue was silently dropped:
+// resumeFromState pre-marked frontierSeen for resumed URLs, so the later
+// markQueued call in processTask treated them as already-seen duplicates
+// and returned before ever fetching them.
+func TestCrawlerResumesPendingQueue(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	dbPath := filepath.Join(t.TempDir(), "state.db")
+	state, err := OpenBoltState(dbPath)
+	if err != nil {
+		t.Fatalf("OpenBoltState: %v", err)
+	}
+
+	pending := []string{srv.URL + "/a", srv.URL + "/b", srv.URL + "/c"}
+	for _, u := range pending {
+		if err := state.Enqueue(u, ""); err != nil {
+			t.Fatalf("Enqueue(%s): %v", u, err)
+		}
+	}
+
+	c, err := NewCrawler(srv.URL+"/start", false, 0, 5*time.Second, false, 0, state, nil, NewDepthScope(10), 4, 0, false, 0)
+	if err != nil {
+		t.Fatalf("NewCrawler: %v", err)
+	}
+
+	c.Run(context.Background())
+	results := c.Wait()
+	if err := state.Close(); err != nil {
+		t.Fatalf("state.Close: %v", err)
+	}
+
+	statusByURL := make(map[string]int)
+	for _, r := range results {
+		statusByURL[r.URL] = r.Status
+	}
+	for _, u := range pending {
+		status, ok := statusByURL[u]
+		if !ok {
+			t.Errorf("resumed URL %s produced no result at all", u)
+			continue
+		}
+		if status != http.StatusOK {
+			t.Errorf("resumed URL %s: got status %d, want %d", u, status, http.StatusOK)
+		}
+	}
+
+	reopened, err := OpenBoltState(dbPath)
+	if err != nil {
+		t.Fatalf("reopen OpenBoltState: %v", err)
+	}
+	defer reopened.Close()
+
+	stillPending, err := reopened.PendingQueue()
+	if err != nil {
+		t.Fatalf("PendingQueue: %v", err)
+	}
+	for _, u := range pending {
+		if _, ok := stillPending[u]; ok {
+			t.Errorf("resumed URL %s is still in the pending queue after the crawl", u)
+		}
+		visited, err := reopened.IsVisited(u)
+		if err != nil {
+			t.Fatalf("IsVisited(%s): %v", u, err)
+		}
+		if !visited {
+			t.Errorf("resumed URL %s was never marked visited", u)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// DomainLog is an append-only, newline-delimited list of discovered
+// registrable domains, used so a `--mode domains` crawl can resume from
+// where a previous, interrupted run left off instead of rediscovering
+// everything.
+type DomainLog struct {
+	mu   sync.Mutex
+	seen map[string]bool
+	f    *os.File
+}
+
+// OpenDomainLog opens (creating if necessary) the domain list at path,
+// returning it alongside every domain already recorded there.
+func OpenDomainLog(path string) (*DomainLog, []string, error) {
+	var existing []string
+	if f, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				existing = append(existing, line)
+			}
+		}
+		f.Close()
+	} else if !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := make(map[string]bool, len(existing))
+	for _, d := range existing {
+		seen[d] = true
+	}
+	return &DomainLog{seen: seen, f: f}, existing, nil
+}
+
+// Record appends domain to the log if it hasn't been seen before.
+func (d *DomainLog) Record(domain string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.seen[domain] {
+		return
+	}
+	d.seen[domain] = true
+	fmt.Fprintln(d.f, domain)
+}
+
+// Close flushes and closes the underlying file.
+func (d *DomainLog) Close() error {
+	return d.f.Close()
+}
+
+// DomainScope lets the crawl follow links across hosts, unlike SeedScope,
+// but bounds how far it branches: --max-links-per-host stops letting
+// through links that point to a given host once that many have been let
+// through (links are counted by their own host, not by the page they were
+// found on, and a URL seen again counts again), and
+// --max-subdomains-per-domain stops
+// a single registrable domain (e.g. blogspot.com) from spawning an
+// unbounded number of subdomains. Every newly seen host's registrable
+// domain is recorded in a DomainLog as it's first allowed through.
+type DomainScope struct {
+	maxLinksPerHost int
+	maxSubdomains   int
+	log             *DomainLog
+
+	mu            sync.Mutex
+	linksPerHost  map[string]int
+	subsPerDomain map[string]map[string]bool
+}
+
+// NewDomainScope returns a DomainScope with the given caps (0 = unbounded)
+// and, optionally, a DomainLog to persist discoveries to.
+func NewDomainScope(maxLinksPerHost, maxSubdomains int, log *DomainLog) *DomainScope {
+	return &DomainScope{
+		maxLinksPerHost: maxLinksPerHost,
+		maxSubdomains:   maxSubdomains,
+		log:             log,
+		linksPerHost:    make(map[string]int),
+		subsPerDomain:   make(map[string]map[string]bool),
+	}
+}
+
+func (s *DomainScope) Decide(link Link, depth int) ScopeDecision {
+	if d, ok := relatedLinksAlwaysFetched(link); ok {
+		return d
+	}
+	if link.URL.Scheme != "http" && link.URL.Scheme != "https" {
+		return ScopeDrop
+	}
+
+	host := strings.ToLower(link.URL.Host)
+	domain, err := registrableDomain(host)
+	if err != nil {
+		domain = host
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxLinksPerHost > 0 && s.linksPerHost[host] >= s.maxLinksPerHost {
+		return ScopeDrop
+	}
+
+	subs := s.subsPerDomain[domain]
+	if subs == nil {
+		subs = make(map[string]bool)
+		s.subsPerDomain[domain] = subs
+	}
+	isNewHost := !subs[host]
+	if isNewHost && s.maxSubdomains > 0 && len(subs) >= s.maxSubdomains {
+		return ScopeDrop
+	}
+
+	subs[host] = true
+	s.linksPerHost[host]++
+	if isNewHost && s.log != nil {
+		s.log.Record(domain)
+	}
+	return ScopeDescend
+}
+
+// registrableDomain returns the eTLD+1 for host (stripping any port). IP
+// literal hosts (e.g. "127.0.0.1") have no registrable domain in the
+// public-suffix sense, and EffectiveTLDPlusOne doesn't error on them (it
+// just returns garbage, treating the last two dot-separated components as
+// a domain+TLD), so they're special-cased and returned as-is.
+func registrableDomain(host string) (string, error) {
+	if h, _, err := splitHostPort(host); err == nil {
+		host = h
+	}
+	if net.ParseIP(host) != nil {
+		return host, nil
+	}
+	return publicsuffix.EffectiveTLDPlusOne(host)
+}
+
+func splitHostPort(host string) (string, string, error) {
+	u := &url.URL{Host: host}
+	h := u.Hostname()
+	if h == "" {
+		return host, "", fmt.Errorf("domains: empty host")
+	}
+	return h, u.Port(), nil
+}
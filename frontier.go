@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/url"
+	"sync"
+)
+
+// crawlTask is one link waiting to be fetched by a worker.
+type crawlTask struct {
+	link    Link
+	referer *url.URL
+	depth   int
+}
+
+// frontier is an unbounded, concurrency-safe FIFO queue of crawlTasks
+// shared by the worker pool. Unlike a fixed-size channel, it never blocks
+// a producer: a page with thousands of links can be fully queued by one
+// worker without deadlocking the rest of the pool.
+type frontier struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	items  []crawlTask
+	closed bool
+}
+
+func newFrontier() *frontier {
+	f := &frontier{}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// push adds a task to the back of the queue.
+func (f *frontier) push(t crawlTask) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.items = append(f.items, t)
+	f.cond.Signal()
+}
+
+// pop removes and returns the task at the front of the queue, blocking
+// until one is available or the frontier is closed. ok is false once the
+// queue is closed and drained.
+func (f *frontier) pop() (t crawlTask, ok bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.items) == 0 && !f.closed {
+		f.cond.Wait()
+	}
+	if len(f.items) == 0 {
+		return crawlTask{}, false
+	}
+	t, f.items = f.items[0], f.items[1:]
+	return t, true
+}
+
+// close signals that no more tasks will be pushed, waking any workers
+// blocked in pop once the queue is empty.
+func (f *frontier) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	f.cond.Broadcast()
+}
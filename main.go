@@ -2,237 +2,18 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
-	"math/rand"
-	"net/http"
-	"net/http/cookiejar"
-	"net/url"
 	"os"
 	"path"
 	"strings"
-	"sync"
 	"time"
 
-	"golang.org/x/net/html"
+	"github.com/vitovt/go_check_links/report"
+	"github.com/vitovt/go_check_links/warc"
 )
 
-type LinkStatus struct {
-	URL    string
-	Status int
-	Err    error
-}
-
-type Crawler struct {
-	startURL    *url.URL
-	visited     map[string]bool
-	visitedLock sync.Mutex
-	client      *http.Client
-	results     chan LinkStatus
-	wg          sync.WaitGroup
-	userAgent   string
-
-	delay        time.Duration
-	debug        bool
-	maxNum       int
-	visitedCount int
-}
-
-// NewCrawler initializes a crawler with a given starting URL.
-func NewCrawler(startURL string, ignoreCert bool, delay time.Duration, timeout time.Duration, debug bool, maxNum int) (*Crawler, error) {
-	u, err := url.Parse(startURL)
-	if err != nil {
-		return nil, err
-	}
-
-	jar, err := cookiejar.New(nil)
-	if err != nil {
-		return nil, err
-	}
-
-	// Create a custom transport with optional certificate check ignoring
-	transport := &http.Transport{
-		// Optional: custom settings, proxies, timeouts, etc.
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: ignoreCert},
-	}
-
-	client := &http.Client{
-		Jar:       jar,
-		Transport: transport,
-		// Timeout: time.Second * 10, // optionally set a timeout
-		Timeout:   timeout,
-	}
-
-	return &Crawler{
-		startURL:  u,
-		visited:   make(map[string]bool),
-		client:    client,
-		results:   make(chan LinkStatus, 1000),
-		userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/115.0.0.0 Safari/537.36",
-		delay:     delay,
-		debug:     debug,
-		maxNum:    maxNum,
-	}, nil
-}
-
-// Run starts the crawling process.
-func (c *Crawler) Run(ctx context.Context) {
-	c.wg.Add(1)
-	go c.crawlURL(ctx, c.startURL, nil)
-	// Close results channel once all work is done.
-	go func() {
-		c.wg.Wait()
-		close(c.results)
-	}()
-}
-
-// Wait waits for the crawl results to finish and returns them.
-func (c *Crawler) Wait() []LinkStatus {
-	var allResults []LinkStatus
-	for r := range c.results {
-		allResults = append(allResults, r)
-	}
-	return allResults
-}
-
-func (c *Crawler) markVisited(u string) bool {
-	c.visitedLock.Lock()
-	defer c.visitedLock.Unlock()
-
-	// If maxNum > 0, limit the number of pages visited
-	if c.maxNum > 0 && c.visitedCount >= c.maxNum {
-		return false
-	}
-
-	if c.visited[u] {
-		return false
-	}
-	c.visited[u] = true
-	c.visitedCount++
-	return true
-}
-
-// crawlURL fetches the given URL, checks it, and if it is an HTML page, parses it for more links.
-// referer is the URL from which we found this link, can be nil if it's the start page.
-func (c *Crawler) crawlURL(ctx context.Context, u *url.URL, referer *url.URL) {
-	defer c.wg.Done()
-
-	if !c.shouldCrawl(u) {
-		return
-	}
-
-	uStr := u.String()
-	if !c.markVisited(uStr) {
-		// Already visited or max reached
-		return
-	}
-
-	// Random small delay to mimic human browsing
-	// If delay is set, sleep a random duration up to that delay
-	if c.delay > 0 {
-		time.Sleep(time.Duration(rand.Int63n(int64(c.delay))))
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "GET", uStr, nil)
-	if err != nil {
-		c.results <- LinkStatus{URL: uStr, Err: err}
-		return
-	}
-
-	// Set some "browser-like" headers
-	req.Header.Set("User-Agent", c.userAgent)
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,image/apng,*/*;q=0.8")
-	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
-	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
-	if referer != nil {
-		req.Header.Set("Referer", referer.String())
-	}
-
-	resp, err := c.client.Do(req)
-	status := 0
-	if resp != nil {
-		status = resp.StatusCode
-	}
-	c.results <- LinkStatus{URL: uStr, Status: status, Err: err}
-
-	if err != nil {
-		// Can't proceed if request failed
-		return
-	}
-	defer resp.Body.Close()
-
-	// Only parse HTML pages
-	ct := resp.Header.Get("Content-Type")
-	if !strings.Contains(strings.ToLower(ct), "text/html") {
-		return
-	}
-
-	doc, err := html.Parse(resp.Body)
-	if err != nil {
-		return
-	}
-
-	// If debug enabled, print HTML content to stdout
-	if c.debug {
-		// resp.Body was already read, we need to re-fetch it or store content earlier if we want full HTML.
-		// Alternatively, just print a message indicating debug is on.
-		// A more complex approach is needed to actually show content here (like reading it fully before parsing).
-		// For demonstration purposes, we will simply print a message.
-		// If you need full content, you'd have to buffer resp.Body before parsing.
-		fmt.Printf("DEBUG: Retrieved HTML for %s (not fully implemented to show content)\n", uStr)
-	}
-
-	links := extractLinks(doc, u)
-	for _, link := range links {
-		c.wg.Add(1)
-		go c.crawlURL(ctx, link, u)
-	}
-}
-
-// shouldCrawl checks if the URL is within the same host and scheme.
-func (c *Crawler) shouldCrawl(u *url.URL) bool {
-	// Only follow same scheme/host
-	if !strings.EqualFold(u.Host, c.startURL.Host) || u.Scheme != c.startURL.Scheme {
-		return false
-	}
-	return true
-}
-
-// extractLinks finds all <a href=...> and <img src=...> links from the parsed HTML and returns absolute URLs.
-func extractLinks(n *html.Node, base *url.URL) []*url.URL {
-	var links []*url.URL
-	var f func(*html.Node)
-	f = func(node *html.Node) {
-		if node.Type == html.ElementNode {
-			var keyAttr string
-			switch node.Data {
-			case "a":
-				keyAttr = "href"
-			case "img":
-				keyAttr = "src"
-			}
-			if keyAttr != "" {
-				for _, attr := range node.Attr {
-					if attr.Key == keyAttr {
-						u, err := url.Parse(strings.TrimSpace(attr.Val))
-						if err == nil {
-							resolved := base.ResolveReference(u)
-							links = append(links, resolved)
-						}
-					}
-				}
-			}
-		}
-		for child := node.FirstChild; child != nil; child = child.NextSibling {
-			f(child)
-		}
-	}
-	f(n)
-	return links
-}
-
 func printHelp(progName string) {
 	fmt.Printf("Usage: %s [options] <start-url>\n", progName)
 	fmt.Println()
@@ -246,9 +27,29 @@ func printHelp(progName string) {
 	fmt.Println("  -t, --timeout DURATION  Set the HTTP client timeout (e.g. 10s, 5s). Default: 10s")
 	fmt.Println("  -D, --debug          Print retrieved HTML content info (for debugging).")
 	fmt.Println("  -m, --max-num N      Restrict the maximum number of pages to scan. Default: no limit")
+	fmt.Println("  -o, --output PATH    Write every fetched request/response to a gzipped WARC file at PATH.")
+	fmt.Println("      --state PATH     Persist crawl state (queue, visited set, status) to a bbolt database at PATH")
+	fmt.Println("                       and resume from it on restart instead of re-fetching everything.")
+	fmt.Println("      --scope SPEC     Scope terms restricting which links are descended into. Comma-separated")
+	fmt.Println("                       terms are ANDed, semicolon-separated alternatives are ORed.")
+	fmt.Println("                       Terms: seed, depth=N, regexp=PATTERN. Default: seed (same host as <start-url>).")
+	fmt.Println("  -c, --concurrency N  Number of worker goroutines fetching links concurrently. Default: 10")
+	fmt.Println("      --per-host-qps N Maximum requests per second sent to any single host (0 = unlimited). Default: 0")
+	fmt.Println("                       robots.txt Crawl-delay, when stricter, is also honored per host.")
+	fmt.Println("      --report-format F  Report format: text, json, junit, sarif, or sitemap. Default: text")
+	fmt.Println("      --report-output PATH  Write the report to PATH instead of stdout.")
+	fmt.Println("      --mode MODE      \"links\" (default) checks for broken links; \"domains\" harvests unique")
+	fmt.Println("                       registrable domains reachable by following links across hosts.")
+	fmt.Println("      --domains-output PATH  (mode domains) Append newly discovered domains to PATH and")
+	fmt.Println("                       resume from it on restart. Required in domains mode.")
+	fmt.Println("      --max-links-per-host N  (mode domains) Stop branching once N links from a single host")
+	fmt.Println("                       have been queued (0 = unlimited). Default: 0")
+	fmt.Println("      --max-subdomains-per-domain N  (mode domains) Cap distinct subdomains queued per")
+	fmt.Println("                       registrable domain, to avoid *.blogspot.com-style traps (0 = unlimited).")
+	fmt.Println("      --max-body-size BYTES  (mode domains) Cap fetched body size. Default: 1048576 (1 MiB)")
 	fmt.Println()
 	fmt.Println("Example:")
-	fmt.Printf("  %s -i -d 2s -t 5s -D -m 100 https://example.com\n", progName)
+	fmt.Printf("  %s -i -d 2s -t 5s -D -m 100 -o crawl.warc.gz --state crawl.db https://example.com\n", progName)
 }
 
 func main() {
@@ -263,6 +64,8 @@ func main() {
 		"--timeout":     "-t",
 		"--debug":       "-D",
 		"--max-num":     "-m",
+		"--output":      "-o",
+		"--concurrency": "-c",
 	}
 	processedArgs := []string{os.Args[0]}
 	for _, arg := range os.Args[1:] {
@@ -275,6 +78,10 @@ func main() {
 			processedArgs = append(processedArgs, "-t"+strings.TrimPrefix(arg, "--timeout"))
 		} else if strings.HasPrefix(arg, "--max-num=") {
 			processedArgs = append(processedArgs, "-m"+strings.TrimPrefix(arg, "--max-num"))
+		} else if strings.HasPrefix(arg, "--output=") {
+			processedArgs = append(processedArgs, "-o"+strings.TrimPrefix(arg, "--output"))
+		} else if strings.HasPrefix(arg, "--concurrency=") {
+			processedArgs = append(processedArgs, "-c"+strings.TrimPrefix(arg, "--concurrency"))
 		} else {
 			processedArgs = append(processedArgs, arg)
 		}
@@ -287,6 +94,18 @@ func main() {
 	timeout := flag.Duration("t", 10*time.Second, "HTTP client timeout")
 	debug := flag.Bool("D", false, "Print retrieved HTML content for debugging")
 	maxNum := flag.Int("m", 0, "Maximum number of pages to scan (0 = no limit)")
+	output := flag.String("o", "", "Write every fetched request/response to a gzipped WARC file at this path")
+	statePath := flag.String("state", "", "Persist and resume crawl state (queue, visited set, status) from a bbolt database at this path")
+	scopeSpec := flag.String("scope", "seed", "Scope terms (seed, depth=N, regexp=PATTERN) restricting which links are descended into; comma-separated terms are ANDed, semicolon-separated alternatives are ORed")
+	concurrency := flag.Int("c", 10, "Number of worker goroutines fetching links concurrently")
+	perHostQPS := flag.Float64("per-host-qps", 0, "Maximum requests per second sent to any single host (0 = unlimited)")
+	reportFormat := flag.String("report-format", "text", "Report format: text, json, junit, sarif, or sitemap")
+	reportOutput := flag.String("report-output", "", "Write the report to this path instead of stdout")
+	mode := flag.String("mode", "links", `Crawl mode: "links" (check for broken links) or "domains" (harvest unique registrable domains)`)
+	domainsOutput := flag.String("domains-output", "", "(mode domains) Append newly discovered domains to this path and resume from it on restart")
+	maxLinksPerHost := flag.Int("max-links-per-host", 0, "(mode domains) Stop branching once N links from a single host have been queued (0 = unlimited)")
+	maxSubdomains := flag.Int("max-subdomains-per-domain", 0, "(mode domains) Cap distinct subdomains queued per registrable domain (0 = unlimited)")
+	maxBodySize := flag.Int64("max-body-size", 1<<20, "(mode domains) Cap fetched body size in bytes")
 
 	helpFlag := flag.Bool("h", false, "Show help message")
 
@@ -305,46 +124,97 @@ func main() {
 	start := flag.Arg(0)
 	ctx := context.Background()
 
-	c, err := NewCrawler(start, *ignoreCert, *delay, *timeout, *debug, *maxNum)
+	var state CrawlState
+	if *statePath != "" {
+		s, err := OpenBoltState(*statePath)
+		if err != nil {
+			log.Fatalf("Error opening state database %s: %v", *statePath, err)
+		}
+		defer s.Close()
+		state = s
+	}
+
+	var warcWriter *warc.Writer
+	if *output != "" {
+		w, err := warc.Create(*output, crawlerUserAgent)
+		if err != nil {
+			log.Fatalf("Error creating WARC file %s: %v", *output, err)
+		}
+		defer w.Close()
+		warcWriter = w
+	}
+
+	domainMode := *mode == "domains"
+	if domainMode && *domainsOutput == "" {
+		log.Fatalf("--domains-output is required in --mode domains")
+	}
+
+	var scope Scope
+	var domainLog *DomainLog
+	var previousDomains []string
+	if domainMode {
+		dl, existing, err := OpenDomainLog(*domainsOutput)
+		if err != nil {
+			log.Fatalf("Error opening domains output %s: %v", *domainsOutput, err)
+		}
+		defer dl.Close()
+		domainLog = dl
+		previousDomains = existing
+		scope = NewDomainScope(*maxLinksPerHost, *maxSubdomains, domainLog)
+	} else {
+		s, err := ParseScope(*scopeSpec, []string{start})
+		if err != nil {
+			log.Fatalf("Error parsing --scope %q: %v", *scopeSpec, err)
+		}
+		scope = s
+	}
+
+	c, err := NewCrawler(start, *ignoreCert, *delay, *timeout, *debug, *maxNum, state, warcWriter, scope, *concurrency, *perHostQPS, domainMode, *maxBodySize)
 	if err != nil {
 		log.Fatalf("Error initializing crawler: %v", err)
 	}
 
+	if domainMode && len(previousDomains) > 0 {
+		var seedURLs []string
+		for _, domain := range previousDomains {
+			seedURLs = append(seedURLs, "https://"+domain+"/")
+		}
+		c.SeedExtra(seedURLs)
+	}
+
 	log.Printf("Starting crawl at: %s (ignore cert: %v, delay: %v, timeout: %v, debug: %v, max-num: %d)",
 		start, *ignoreCert, *delay, *timeout, *debug, *maxNum)
 	c.Run(ctx)
 	results := c.Wait()
 
-	log.Println("Crawl finished. Results:")
-	var brokenLinks []LinkStatus
-	for _, r := range results {
-		if r.Err != nil || (r.Status >= 400 && r.Status < 600) {
-			brokenLinks = append(brokenLinks, r)
+	reportResults := make([]report.Result, len(results))
+	var brokenCount int
+	for i, r := range results {
+		rr := report.Result{URL: r.URL, Status: r.Status, Reason: r.Reason, Referer: r.Referer, LastModified: r.LastModified}
+		if r.Err != nil {
+			rr.Err = r.Err.Error()
+		}
+		reportResults[i] = rr
+		if rr.Broken() {
+			brokenCount++
 		}
 	}
 
-	for _, r := range results {
-		if r.Err != nil {
-			log.Printf("[BROKEN] %s -> Error: %v", r.URL, r.Err)
-		} else {
-			if r.Status >= 400 && r.Status < 600 {
-				log.Printf("[BROKEN] %s -> HTTP %d", r.URL, r.Status)
-			} else {
-				log.Printf("[OK] %s -> HTTP %d", r.URL, r.Status)
-			}
+	out := os.Stdout
+	if *reportOutput != "" {
+		f, err := report.Create(*reportOutput)
+		if err != nil {
+			log.Fatalf("Error creating report file %s: %v", *reportOutput, err)
 		}
+		defer f.Close()
+		out = f
 	}
 
-	if len(brokenLinks) == 0 {
-		log.Println("No broken links found!")
-	} else {
-		log.Printf("Found %d broken links:", len(brokenLinks))
-		for _, b := range brokenLinks {
-			if b.Err != nil {
-				log.Printf(" - %s (%v)", b.URL, b.Err)
-			} else {
-				log.Printf(" - %s (Status: %d)", b.URL, b.Status)
-			}
-		}
+	if err := report.Write(out, *reportFormat, reportResults); err != nil {
+		log.Fatalf("Error writing %s report: %v", *reportFormat, err)
+	}
+
+	if brokenCount > 0 {
+		os.Exit(1)
 	}
 }
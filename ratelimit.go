@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// hostLimiter hands out a per-host token bucket limiter, creating one on
+// first use. A qps of 0 means unlimited, unless a per-request crawlDelay
+// (from robots.txt) requires pacing anyway.
+type hostLimiter struct {
+	qps float64
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func newHostLimiter(qps float64) *hostLimiter {
+	return &hostLimiter{qps: qps, limiters: make(map[string]*rate.Limiter)}
+}
+
+// forHost returns the limiter for host, creating or slowing it down so it
+// honors both --per-host-qps and a robots.txt Crawl-delay (the stricter of
+// the two wins). Returns nil if neither applies.
+func (h *hostLimiter) forHost(host string, crawlDelay time.Duration) *rate.Limiter {
+	limit := rate.Inf
+	if h.qps > 0 {
+		limit = rate.Limit(h.qps)
+	}
+	if crawlDelay > 0 {
+		if fromDelay := rate.Limit(1 / crawlDelay.Seconds()); fromDelay < limit {
+			limit = fromDelay
+		}
+	}
+	if limit == rate.Inf {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	l, ok := h.limiters[host]
+	if !ok {
+		// Burst of 1: never let a host absorb a queue of saved-up tokens
+		// and get hit with a burst after being idle.
+		l = rate.NewLimiter(limit, 1)
+		h.limiters[host] = l
+	} else if limit < l.Limit() {
+		l.SetLimit(limit)
+	}
+	return l
+}
@@ -0,0 +1,300 @@
+// Package report renders crawl results as text, JSON, JUnit XML, SARIF, or
+// a sitemap.xml, for consumption by humans or CI tooling.
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Result is one checked link, independent of how the crawler represents it
+// internally so this package has no dependency on the main crawler code.
+type Result struct {
+	URL          string `json:"url"`
+	Status       int    `json:"status,omitempty"`
+	Err          string `json:"error,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+	Referer      string `json:"referer,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// Broken reports whether r represents a broken (as opposed to skipped or
+// successfully fetched) link.
+func (r Result) Broken() bool {
+	return !r.Skipped() && (r.Err != "" || (r.Status >= 400 && r.Status < 600))
+}
+
+// Skipped reports whether r was never actually fetched because scope,
+// robots.txt, or rate limiting stepped in first, as opposed to having
+// succeeded or failed at the HTTP level.
+func (r Result) Skipped() bool {
+	switch r.Reason {
+	case "scope", "robots", "rate-limit":
+		return true
+	default:
+		return false
+	}
+}
+
+// RefererChain walks r's Referer field back through results (keyed by URL)
+// to the start page, returning the chain of URLs from start to r, r last.
+func RefererChain(results []Result, r Result) []string {
+	byURL := make(map[string]Result, len(results))
+	for _, res := range results {
+		byURL[res.URL] = res
+	}
+
+	var chain []string
+	seen := make(map[string]bool)
+	cur := r
+	for {
+		chain = append([]string{cur.URL}, chain...)
+		if cur.Referer == "" || seen[cur.Referer] {
+			break
+		}
+		seen[cur.URL] = true
+		parent, ok := byURL[cur.Referer]
+		if !ok {
+			chain = append([]string{cur.Referer}, chain...)
+			break
+		}
+		cur = parent
+	}
+	return chain
+}
+
+// Write renders results in format ("text", "json", "junit", "sarif", or
+// "sitemap") to w.
+func Write(w io.Writer, format string, results []Result) error {
+	switch format {
+	case "", "text":
+		return writeText(w, results)
+	case "json":
+		return writeJSON(w, results)
+	case "junit":
+		return writeJUnit(w, results)
+	case "sarif":
+		return writeSARIF(w, results)
+	case "sitemap":
+		return writeSitemap(w, results)
+	default:
+		return fmt.Errorf("report: unknown format %q", format)
+	}
+}
+
+func writeText(w io.Writer, results []Result) error {
+	var broken int
+	for _, r := range results {
+		switch {
+		case r.Reason == "scope":
+			// Out-of-scope links were never meant to be checked at all;
+			// printing them would just be noise.
+			continue
+		case r.Skipped():
+			fmt.Fprintf(w, "[SKIPPED] %s (%s)\n", r.URL, r.Reason)
+		case r.Broken():
+			broken++
+			fmt.Fprintf(w, "[BROKEN] %s -> %s\n", r.URL, statusText(r))
+		default:
+			fmt.Fprintf(w, "[OK] %s -> HTTP %d\n", r.URL, r.Status)
+		}
+	}
+	if broken == 0 {
+		fmt.Fprintln(w, "No broken links found!")
+	} else {
+		fmt.Fprintf(w, "Found %d broken links.\n", broken)
+	}
+	return nil
+}
+
+func statusText(r Result) string {
+	if r.Err != "" {
+		return fmt.Sprintf("Error: %s (%s)", r.Err, r.Reason)
+	}
+	return fmt.Sprintf("HTTP %d (%s)", r.Status, r.Reason)
+}
+
+// jsonResult is Result plus the full chain of referers back to the start
+// page, since the JSON report is the one format that surfaces it.
+type jsonResult struct {
+	Result
+	RefererChain []string `json:"refererChain,omitempty"`
+}
+
+func writeJSON(w io.Writer, results []Result) error {
+	out := make([]jsonResult, len(results))
+	for i, r := range results {
+		out[i] = jsonResult{Result: r}
+		if r.Referer != "" {
+			out[i].RefererChain = RefererChain(results, r)
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// JUnit XML, the minimal subset Jenkins/GitLab/GitHub Actions understand:
+// one <testcase> per checked URL, with a <failure> for broken links.
+
+type junitTestsuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Testcases []junitTestcase `xml:"testcase"`
+}
+
+type junitTestcase struct {
+	Name    string        `xml:"name,attr"`
+	Failure *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func writeJUnit(w io.Writer, results []Result) error {
+	suite := junitTestsuite{Name: "go_check_links"}
+	for _, r := range results {
+		if r.Skipped() {
+			continue
+		}
+		tc := junitTestcase{Name: r.URL}
+		if r.Broken() {
+			suite.Failures++
+			tc.Failure = &junitFailure{Message: statusText(r), Text: fmt.Sprintf("referer: %s", r.Referer)}
+		}
+		suite.Testcases = append(suite.Testcases, tc)
+		suite.Tests++
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(suite)
+}
+
+// SARIF, the format GitHub/GitLab code-scanning UIs consume.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+func writeSARIF(w io.Writer, results []Result) error {
+	log := sarifLog{
+		Schema:  "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:  "go_check_links",
+				Rules: []sarifRule{{ID: "broken-link"}},
+			}},
+		}},
+	}
+
+	for _, r := range results {
+		if !r.Broken() {
+			continue
+		}
+		log.Runs[0].Results = append(log.Runs[0].Results, sarifResult{
+			RuleID:  "broken-link",
+			Level:   "error",
+			Message: sarifMessage{Text: statusText(r)},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: r.URL},
+				},
+			}},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(log)
+}
+
+// sitemap.xml, listing every successfully crawled page.
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+func writeSitemap(w io.Writer, results []Result) error {
+	set := sitemapURLSet{Xmlns: "http://www.sitemaps.org/schemas/sitemap/0.9"}
+	for _, r := range results {
+		if r.Status < 200 || r.Status >= 300 {
+			continue
+		}
+		set.URLs = append(set.URLs, sitemapURL{Loc: r.URL, LastMod: r.LastModified})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(set)
+}
+
+// Create opens path for writing a report, creating it if necessary and
+// truncating any existing file. Callers are responsible for closing it.
+func Create(path string) (*os.File, error) {
+	return os.Create(path)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// robotsRules are the rules from one host's robots.txt that apply to our
+// User-Agent (either a group matching it by name, or the "*" group).
+type robotsRules struct {
+	disallow   []string
+	allow      []string
+	crawlDelay time.Duration
+}
+
+// allowed reports whether path may be fetched, using the usual robots.txt
+// longest-match-wins rule between Allow and Disallow.
+func (r *robotsRules) allowed(path string) bool {
+	if r == nil {
+		return true
+	}
+
+	bestLen := -1
+	bestAllow := true
+	check := func(prefixes []string, allow bool) {
+		for _, p := range prefixes {
+			if p == "" {
+				continue
+			}
+			if strings.HasPrefix(path, p) && len(p) > bestLen {
+				bestLen = len(p)
+				bestAllow = allow
+			}
+		}
+	}
+	check(r.disallow, false)
+	check(r.allow, true)
+	return bestAllow
+}
+
+// RobotsCache fetches and caches robots.txt, one per host, for use before
+// every crawl request.
+type RobotsCache struct {
+	client    *http.Client
+	userAgent string
+
+	mu    sync.Mutex
+	cache map[string]*robotsRules
+}
+
+// NewRobotsCache returns a cache that fetches robots.txt using client and
+// evaluates rules for userAgent.
+func NewRobotsCache(client *http.Client, userAgent string) *RobotsCache {
+	return &RobotsCache{client: client, userAgent: userAgent, cache: make(map[string]*robotsRules)}
+}
+
+// Get returns the cached rules for u's host, fetching and parsing
+// robots.txt on first use. A fetch failure (including 4xx/5xx) is treated
+// as "no restrictions", per the usual robots.txt convention.
+func (c *RobotsCache) Get(ctx context.Context, u *url.URL) *robotsRules {
+	c.mu.Lock()
+	if rules, ok := c.cache[u.Host]; ok {
+		c.mu.Unlock()
+		return rules
+	}
+	c.mu.Unlock()
+
+	rules := c.fetch(ctx, u)
+
+	c.mu.Lock()
+	c.cache[u.Host] = rules
+	c.mu.Unlock()
+	return rules
+}
+
+func (c *RobotsCache) fetch(ctx context.Context, u *url.URL) *robotsRules {
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", robotsURL.String(), nil)
+	if err != nil {
+		return nil
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil
+	}
+
+	return parseRobots(resp.Body, c.userAgent)
+}
+
+// robotsGroup is one "User-agent: ..." block and the rules under it.
+type robotsGroup struct {
+	agents []string
+	rules  robotsRules
+}
+
+// parseRobots implements the subset of the robots.txt format needed here:
+// User-agent/Disallow/Allow/Crawl-delay, grouped by User-agent blocks. The
+// most specific group whose agent name is a prefix of userAgent is used,
+// falling back to the "*" catch-all group.
+func parseRobots(r io.Reader, userAgent string) *robotsRules {
+	var groups []*robotsGroup
+	var current *robotsGroup
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+		field, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		field = strings.ToLower(strings.TrimSpace(field))
+		value = strings.TrimSpace(value)
+
+		switch field {
+		case "user-agent":
+			if current == nil || len(current.rules.disallow) > 0 || len(current.rules.allow) > 0 || current.rules.crawlDelay > 0 {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, value)
+		case "disallow":
+			if current != nil {
+				current.rules.disallow = append(current.rules.disallow, value)
+			}
+		case "allow":
+			if current != nil {
+				current.rules.allow = append(current.rules.allow, value)
+			}
+		case "crawl-delay":
+			if current != nil {
+				if secs, err := strconv.ParseFloat(value, 64); err == nil {
+					current.rules.crawlDelay = time.Duration(secs * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	var wildcard, best *robotsGroup
+	bestLen := -1
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			if agent == "*" {
+				wildcard = g
+			} else if len(agent) > bestLen && strings.HasPrefix(strings.ToLower(userAgent), strings.ToLower(agent)) {
+				best, bestLen = g, len(agent)
+			}
+		}
+	}
+	if best != nil {
+		return &best.rules
+	}
+	if wildcard != nil {
+		return &wildcard.rules
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRobotsDisallowAllowLongestMatch(t *testing.T) {
+	body := `
+User-agent: *
+Disallow: /private/
+Allow: /private/public.html
+Crawl-delay: 2
+`
+	rules := parseRobots(strings.NewReader(body), "MyBot/1.0")
+	if rules == nil {
+		t.Fatal("expected rules, got nil")
+	}
+	if rules.allowed("/private/secret.html") {
+		t.Error("expected /private/secret.html to be disallowed")
+	}
+	if !rules.allowed("/private/public.html") {
+		t.Error("expected /private/public.html to be allowed (more specific Allow wins)")
+	}
+	if !rules.allowed("/about.html") {
+		t.Error("expected /about.html (outside Disallow) to be allowed")
+	}
+	if rules.crawlDelay != 2*time.Second {
+		t.Errorf("crawlDelay = %v, want 2s", rules.crawlDelay)
+	}
+}
+
+func TestParseRobotsSpecificAgentOverridesWildcard(t *testing.T) {
+	body := `
+User-agent: *
+Disallow: /
+
+User-agent: MyBot
+Disallow:
+`
+	rules := parseRobots(strings.NewReader(body), "MyBot/1.0")
+	if rules == nil {
+		t.Fatal("expected rules, got nil")
+	}
+	if !rules.allowed("/anything") {
+		t.Error("expected MyBot's empty Disallow to override the wildcard group")
+	}
+}
+
+func TestParseRobotsFallsBackToWildcard(t *testing.T) {
+	body := `
+User-agent: OtherBot
+Disallow: /
+
+User-agent: *
+Disallow: /blocked/
+`
+	rules := parseRobots(strings.NewReader(body), "MyBot/1.0")
+	if rules == nil {
+		t.Fatal("expected rules, got nil")
+	}
+	if rules.allowed("/blocked/page.html") {
+		t.Error("expected /blocked/page.html to be disallowed by the wildcard group")
+	}
+	if !rules.allowed("/ok.html") {
+		t.Error("expected /ok.html to be allowed")
+	}
+}
+
+func TestRobotsRulesAllowedNilIsPermissive(t *testing.T) {
+	var rules *robotsRules
+	if !rules.allowed("/anything") {
+		t.Error("nil rules (e.g. no robots.txt found) should allow everything")
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LinkType classifies how a link was found on the page, which determines
+// how a Scope is allowed to treat it.
+type LinkType int
+
+const (
+	// LinkTypePrimary is a navigational link (anchor, frame) that the
+	// crawl may follow to discover more pages.
+	LinkTypePrimary LinkType = iota
+	// LinkTypeRelated is a page asset (image, stylesheet, script, etc.)
+	// that should be checked for brokenness but never recursed into.
+	LinkTypeRelated
+)
+
+// Link is a URL extracted from a page, tagged with how it was found.
+type Link struct {
+	URL  *url.URL
+	Type LinkType
+}
+
+// ScopeDecision is what a Scope says to do with a Link.
+type ScopeDecision int
+
+const (
+	// ScopeDrop means the link is ignored entirely: not fetched, not checked.
+	ScopeDrop ScopeDecision = iota
+	// ScopeFetchOnly means the link is fetched and reported on, but never
+	// recursed into even if it turns out to be an HTML page.
+	ScopeFetchOnly
+	// ScopeDescend means the link is fetched and, if it's HTML, its own
+	// links are extracted and fed back into the crawl.
+	ScopeDescend
+)
+
+// Scope decides, for a given link at a given crawl depth, whether the
+// crawler should descend into it, fetch it without recursing, or drop it.
+type Scope interface {
+	Decide(link Link, depth int) ScopeDecision
+}
+
+// relatedLinksAlwaysFetched is shared by scopes that only police primary
+// (navigational) links: related assets are always worth a brokenness check
+// regardless of scope, since they're never recursed into anyway.
+func relatedLinksAlwaysFetched(link Link) (ScopeDecision, bool) {
+	if link.Type == LinkTypeRelated {
+		return ScopeFetchOnly, true
+	}
+	return ScopeDrop, false
+}
+
+// SeedScope restricts the crawl to URLs whose scheme+host match one of a
+// set of seed prefixes. "www." is treated as interchangeable with the
+// bare host, so a seed of "https://example.com" also matches
+// "https://www.example.com" and vice versa.
+type SeedScope struct {
+	prefixes []string
+}
+
+// NewSeedScope builds a SeedScope from one or more seed URLs.
+func NewSeedScope(seeds ...string) (*SeedScope, error) {
+	s := &SeedScope{}
+	for _, seed := range seeds {
+		u, err := url.Parse(seed)
+		if err != nil {
+			return nil, fmt.Errorf("scope: invalid seed %q: %w", seed, err)
+		}
+		host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+		s.prefixes = append(s.prefixes, u.Scheme+"://"+host)
+		s.prefixes = append(s.prefixes, u.Scheme+"://www."+host)
+	}
+	return s, nil
+}
+
+func (s *SeedScope) Decide(link Link, depth int) ScopeDecision {
+	if d, ok := relatedLinksAlwaysFetched(link); ok {
+		return d
+	}
+	host := strings.TrimPrefix(strings.ToLower(link.URL.Host), "www.")
+	prefix := link.URL.Scheme + "://" + host
+	for _, p := range s.prefixes {
+		stripped := strings.TrimPrefix(p, link.URL.Scheme+"://www.")
+		if prefix == p || host == stripped {
+			return ScopeDescend
+		}
+	}
+	return ScopeDrop
+}
+
+// DepthScope caps how many link-hops away from the start URL the crawler
+// will follow primary links. Related assets are exempt from the cap since
+// they're fetched, not recursed into.
+type DepthScope struct {
+	maxDepth int
+}
+
+// NewDepthScope returns a DepthScope allowing primary links up to and
+// including maxDepth hops from the start URL.
+func NewDepthScope(maxDepth int) *DepthScope {
+	return &DepthScope{maxDepth: maxDepth}
+}
+
+func (s *DepthScope) Decide(link Link, depth int) ScopeDecision {
+	if d, ok := relatedLinksAlwaysFetched(link); ok {
+		return d
+	}
+	if depth > s.maxDepth {
+		return ScopeDrop
+	}
+	return ScopeDescend
+}
+
+// RegexpScope restricts primary links to those whose URL matches a regular
+// expression.
+type RegexpScope struct {
+	re *regexp.Regexp
+}
+
+// NewRegexpScope compiles pattern for use as a RegexpScope.
+func NewRegexpScope(pattern string) (*RegexpScope, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("scope: invalid regexp %q: %w", pattern, err)
+	}
+	return &RegexpScope{re: re}, nil
+}
+
+func (s *RegexpScope) Decide(link Link, depth int) ScopeDecision {
+	if d, ok := relatedLinksAlwaysFetched(link); ok {
+		return d
+	}
+	if s.re.MatchString(link.URL.String()) {
+		return ScopeDescend
+	}
+	return ScopeDrop
+}
+
+// AndScope combines scopes so that a link only descends if every child
+// scope allows it; the most restrictive decision wins.
+type AndScope []Scope
+
+func (s AndScope) Decide(link Link, depth int) ScopeDecision {
+	decision := ScopeDescend
+	for _, child := range s {
+		if d := child.Decide(link, depth); d < decision {
+			decision = d
+		}
+	}
+	return decision
+}
+
+// OrScope combines scopes so that a link descends if any child scope
+// allows it; the most permissive decision wins.
+type OrScope []Scope
+
+func (s OrScope) Decide(link Link, depth int) ScopeDecision {
+	decision := ScopeDrop
+	for _, child := range s {
+		if d := child.Decide(link, depth); d > decision {
+			decision = d
+		}
+	}
+	return decision
+}
+
+// ParseScope builds a Scope from a command-line spec such as
+// "seed,depth=10" or "seed;regexp=^https://docs\\.". Comma-separated terms
+// within one alternative are ANDed together; semicolon-separated
+// alternatives are ORed, so a link descends if it satisfies any one of
+// them. seeds is used by the "seed" term.
+func ParseScope(spec string, seeds []string) (Scope, error) {
+	var alternatives OrScope
+	for _, alt := range strings.Split(spec, ";") {
+		scopes, err := parseScopeTerms(alt, seeds)
+		if err != nil {
+			return nil, err
+		}
+		alternatives = append(alternatives, scopes)
+	}
+	if len(alternatives) == 1 {
+		return alternatives[0], nil
+	}
+	return alternatives, nil
+}
+
+// parseScopeTerms builds the AndScope for one comma-separated, semicolon-
+// delimited alternative of a ParseScope spec.
+func parseScopeTerms(spec string, seeds []string) (Scope, error) {
+	var scopes AndScope
+	for _, term := range strings.Split(spec, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(term, "=")
+		switch key {
+		case "seed":
+			s, err := NewSeedScope(seeds...)
+			if err != nil {
+				return nil, err
+			}
+			scopes = append(scopes, s)
+		case "depth":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("scope: invalid depth %q: %w", value, err)
+			}
+			scopes = append(scopes, NewDepthScope(n))
+		case "regexp":
+			s, err := NewRegexpScope(value)
+			if err != nil {
+				return nil, err
+			}
+			scopes = append(scopes, s)
+		default:
+			return nil, fmt.Errorf("scope: unknown term %q", term)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("scope: empty spec")
+	}
+	return scopes, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	bucketVisited = []byte("visited") // url -> JSON(visitedRecord)
+	bucketQueue   = []byte("queue")   // url -> JSON(queuedItem)
+)
+
+// visitedRecord is the persisted outcome of a fetch, keyed by URL.
+type visitedRecord struct {
+	Status int    `json:"status"`
+	Err    string `json:"err,omitempty"`
+}
+
+// queuedItem is a URL discovered but not yet fetched, keyed by URL.
+type queuedItem struct {
+	Referer string `json:"referer,omitempty"`
+}
+
+// CrawlState persists the frontier and visited set so a crawl can resume
+// after a restart instead of starting over. Implementations must be safe
+// for concurrent use.
+type CrawlState interface {
+	// IsVisited reports whether u was already fetched in a previous run.
+	IsVisited(u string) (bool, error)
+	// MarkVisited records the outcome of fetching u and removes it from
+	// the pending queue, if present.
+	MarkVisited(u string, status int, fetchErr error) error
+	// Enqueue records u as discovered-but-not-yet-fetched.
+	Enqueue(u, referer string) error
+	// PendingQueue returns everything still queued from a previous run,
+	// so the crawler can seed its frontier on resume.
+	PendingQueue() (map[string]string, error)
+	Close() error
+}
+
+// BoltState is a CrawlState backed by a bbolt database file.
+type BoltState struct {
+	db *bbolt.DB
+}
+
+// OpenBoltState opens (creating if necessary) a bbolt database at path for
+// use as resumable crawl state.
+func OpenBoltState(path string) (*BoltState, error) {
+	db, err := bbolt.Open(path, 0o644, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(bucketVisited); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(bucketQueue)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltState{db: db}, nil
+}
+
+func (s *BoltState) IsVisited(u string) (bool, error) {
+	var found bool
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		found = tx.Bucket(bucketVisited).Get([]byte(u)) != nil
+		return nil
+	})
+	return found, err
+}
+
+func (s *BoltState) MarkVisited(u string, status int, fetchErr error) error {
+	rec := visitedRecord{Status: status}
+	if fetchErr != nil {
+		rec.Err = fetchErr.Error()
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		if err := tx.Bucket(bucketVisited).Put([]byte(u), data); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketQueue).Delete([]byte(u))
+	})
+}
+
+func (s *BoltState) Enqueue(u, referer string) error {
+	data, err := json.Marshal(queuedItem{Referer: referer})
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		// Don't re-queue something we already have an outcome for.
+		if tx.Bucket(bucketVisited).Get([]byte(u)) != nil {
+			return nil
+		}
+		return tx.Bucket(bucketQueue).Put([]byte(u), data)
+	})
+}
+
+func (s *BoltState) PendingQueue() (map[string]string, error) {
+	pending := make(map[string]string)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketQueue).ForEach(func(k, v []byte) error {
+			var item queuedItem
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			pending[string(k)] = item.Referer
+			return nil
+		})
+	})
+	return pending, err
+}
+
+func (s *BoltState) Close() error {
+	return s.db.Close()
+}
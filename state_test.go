@@ -0,0 +1,85 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBoltStateEnqueueAndPendingQueue(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "state.db")
+	s, err := OpenBoltState(dbPath)
+	if err != nil {
+		t.Fatalf("OpenBoltState: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.Enqueue("https://example.com/a", ""); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if err := s.Enqueue("https://example.com/b", "https://example.com/a"); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	pending, err := s.PendingQueue()
+	if err != nil {
+		t.Fatalf("PendingQueue: %v", err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("PendingQueue returned %d items, want 2", len(pending))
+	}
+	if got := pending["https://example.com/b"]; got != "https://example.com/a" {
+		t.Errorf("referer for /b = %q, want https://example.com/a", got)
+	}
+
+	if err := s.MarkVisited("https://example.com/a", 200, nil); err != nil {
+		t.Fatalf("MarkVisited: %v", err)
+	}
+
+	pending, err = s.PendingQueue()
+	if err != nil {
+		t.Fatalf("PendingQueue: %v", err)
+	}
+	if _, ok := pending["https://example.com/a"]; ok {
+		t.Error("visited URL should be removed from the pending queue")
+	}
+	if _, ok := pending["https://example.com/b"]; !ok {
+		t.Error("unvisited URL should remain pending")
+	}
+
+	visited, err := s.IsVisited("https://example.com/a")
+	if err != nil {
+		t.Fatalf("IsVisited: %v", err)
+	}
+	if !visited {
+		t.Error("expected https://example.com/a to be visited")
+	}
+}
+
+func TestBoltStatePersistsAcrossReopen(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "state.db")
+
+	s, err := OpenBoltState(dbPath)
+	if err != nil {
+		t.Fatalf("OpenBoltState: %v", err)
+	}
+	if err := s.Enqueue("https://example.com/resume-me", ""); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := OpenBoltState(dbPath)
+	if err != nil {
+		t.Fatalf("reopen OpenBoltState: %v", err)
+	}
+	defer reopened.Close()
+
+	pending, err := reopened.PendingQueue()
+	if err != nil {
+		t.Fatalf("PendingQueue: %v", err)
+	}
+	if _, ok := pending["https://example.com/resume-me"]; !ok {
+		t.Error("expected the previously enqueued URL to survive reopening the state file")
+	}
+}
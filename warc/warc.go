@@ -0,0 +1,165 @@
+// Package warc writes crawl traffic to gzip-compressed WARC 1.1 files.
+//
+// Only the subset of the WARC spec needed by the crawler is implemented:
+// a single warcinfo record followed by request/response record pairs,
+// linked via WARC-Concurrent-To.
+package warc
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Writer appends WARC records to a gzip-compressed file. It is safe for
+// concurrent use.
+type Writer struct {
+	f  *os.File
+	gz *gzip.Writer
+	bw *bufio.Writer
+	mu chan struct{} // 1-buffered mutex
+}
+
+// Create opens path for writing and creates it if necessary, truncating any
+// existing file, and writes a warcinfo record describing the crawl.
+func Create(path string, userAgent string) (*Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	gz := gzip.NewWriter(f)
+	bw := bufio.NewWriter(gz)
+
+	w := &Writer{f: f, gz: gz, bw: bw, mu: make(chan struct{}, 1)}
+	w.mu <- struct{}{}
+
+	info := fmt.Sprintf("software: go_check_links\r\nformat: WARC File Format 1.1\r\nconformsTo: http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\nrobots: obey\r\nuser-agent: %s\r\n", userAgent)
+	if err := w.writeRecord("warcinfo", "", newRecordID(), "", time.Now(), []byte(info), "application/warc-fields"); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// WriteExchange records one fetched request/response pair for targetURI.
+// body is the already-drained response body as read from resp.Body; it is
+// not decompressed here, so it keeps any Content-Encoding that resp's
+// headers name.
+func (w *Writer) WriteExchange(targetURI string, req *http.Request, resp *http.Response, body []byte, fetchedAt time.Time) error {
+	w.lock()
+	defer w.unlock()
+
+	reqID := newRecordID()
+	respID := newRecordID()
+
+	reqHeader := requestHeaderBytes(req)
+	if err := w.writeRecord("request", targetURI, reqID, respID, fetchedAt, reqHeader, "application/http; msgtype=request"); err != nil {
+		return err
+	}
+
+	respHeader := responseHeaderBytes(resp, len(body))
+	payload := append(append([]byte{}, respHeader...), body...)
+	if err := w.writeRecord("response", targetURI, respID, reqID, fetchedAt, payload, "application/http; msgtype=response"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Close flushes and closes the underlying gzip member and file.
+func (w *Writer) Close() error {
+	w.lock()
+	defer w.unlock()
+
+	if err := w.bw.Flush(); err != nil {
+		w.gz.Close()
+		w.f.Close()
+		return err
+	}
+	if err := w.gz.Close(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+func (w *Writer) lock()   { <-w.mu }
+func (w *Writer) unlock() { w.mu <- struct{}{} }
+
+func (w *Writer) writeRecord(recordType, targetURI, recordID, concurrentTo string, date time.Time, block []byte, contentType string) error {
+	var headers string
+	headers += "WARC/1.1\r\n"
+	headers += fmt.Sprintf("WARC-Type: %s\r\n", recordType)
+	headers += fmt.Sprintf("WARC-Record-ID: %s\r\n", recordID)
+	headers += fmt.Sprintf("WARC-Date: %s\r\n", date.UTC().Format(time.RFC3339))
+	if targetURI != "" {
+		headers += fmt.Sprintf("WARC-Target-URI: %s\r\n", targetURI)
+	}
+	if concurrentTo != "" {
+		headers += fmt.Sprintf("WARC-Concurrent-To: %s\r\n", concurrentTo)
+	}
+	headers += fmt.Sprintf("Content-Type: %s\r\n", contentType)
+	headers += fmt.Sprintf("Content-Length: %d\r\n", len(block))
+	headers += "\r\n"
+
+	if _, err := io.WriteString(w.bw, headers); err != nil {
+		return err
+	}
+	if _, err := w.bw.Write(block); err != nil {
+		return err
+	}
+	// Records are separated by two CRLFs.
+	_, err := io.WriteString(w.bw, "\r\n\r\n")
+	return err
+}
+
+func requestHeaderBytes(req *http.Request) []byte {
+	var b []byte
+	b = append(b, fmt.Sprintf("%s %s HTTP/1.1\r\n", req.Method, requestTarget(req.URL))...)
+	b = append(b, fmt.Sprintf("Host: %s\r\n", req.URL.Host)...)
+	for k, vv := range req.Header {
+		for _, v := range vv {
+			b = append(b, fmt.Sprintf("%s: %s\r\n", k, v)...)
+		}
+	}
+	b = append(b, "\r\n"...)
+	return b
+}
+
+func responseHeaderBytes(resp *http.Response, bodyLen int) []byte {
+	var b []byte
+	b = append(b, fmt.Sprintf("HTTP/1.1 %d %s\r\n", resp.StatusCode, http.StatusText(resp.StatusCode))...)
+	for k, vv := range resp.Header {
+		if k == "Content-Length" {
+			continue
+		}
+		for _, v := range vv {
+			b = append(b, fmt.Sprintf("%s: %s\r\n", k, v)...)
+		}
+	}
+	b = append(b, fmt.Sprintf("Content-Length: %d\r\n", bodyLen)...)
+	b = append(b, "\r\n"...)
+	return b
+}
+
+func requestTarget(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+	return u.Path + "?" + u.RawQuery
+}
+
+// newRecordID returns a random urn:uuid WARC-Record-ID, as required by the
+// WARC spec for WARC-Record-ID and WARC-Concurrent-To.
+func newRecordID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}